@@ -0,0 +1,181 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"sigs.k8s.io/vsphere-csi-driver/v2/pkg/csi/service/logger"
+)
+
+// healthCheckTimeout bounds how long HealthCheck waits for a single vCenter
+// property fetch before declaring that vCenter unreachable.
+const healthCheckTimeout = 5 * time.Second
+
+var (
+	vcReachableGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_csi_vc_reachable",
+		Help: "Whether the vCenter was reachable on the most recent node manager health check (1) or not (0).",
+	}, []string{"vc_host"})
+	vcLatencyGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_csi_vc_latency_seconds",
+		Help: "Latency of the most recent node manager health check property fetch against the vCenter.",
+	}, []string{"vc_host"})
+	nodesPerVCGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_csi_nodes_per_vc",
+		Help: "Number of cached node VMs backed by the vCenter as of the most recent node manager health check.",
+	}, []string{"vc_host"})
+)
+
+// VCHealth reports the result of a node manager health check against a
+// single vCenter.
+type VCHealth struct {
+	// Reachable is true if the health check's property fetch succeeded.
+	Reachable bool
+	// LatencyMs is how long the property fetch took, in milliseconds.
+	LatencyMs int64
+	// LastError is the error from the property fetch, if Reachable is
+	// false.
+	LastError error
+	// NodeCount is the number of cached node VMs backed by this vCenter.
+	NodeCount int
+}
+
+// HealthCheck groups cached node VMs by vCenter and, for each vCenter,
+// fetches the Name property of a VM as a lightweight connectivity probe,
+// trying up to maxHealthCheckSamples of its VMs until one fetch succeeds.
+// Each vCenter is checked on its own goroutine, bounded by
+// maxConcurrentVCRenewals, so one slow or unreachable vCenter doesn't delay
+// the result for the others. It never returns an error itself; per-vCenter
+// failures are reported via VCHealth so a struggling vCenter degrades
+// observability, not the driver.
+func (m *defaultManager) HealthCheck(ctx context.Context) (map[string]VCHealth, error) {
+	nodesByHost := m.groupNodeVMsByHost(ctx)
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		result = make(map[string]VCHealth, len(nodesByHost))
+		sem    = make(chan struct{}, maxConcurrentVCRenewals)
+	)
+	for vcHost, nodes := range nodesByHost {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(vcHost string, nodes []vcNode) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			health := m.checkVCHealth(ctx, vcHost, nodes)
+			mu.Lock()
+			result[vcHost] = health
+			mu.Unlock()
+		}(vcHost, nodes)
+	}
+	wg.Wait()
+
+	m.pruneStaleHealthGauges(nodesByHost)
+
+	return result, nil
+}
+
+// pruneStaleHealthGauges removes gauge values for vCenters that were
+// reported on a previous HealthCheck but have no cached nodes anymore (e.g.
+// the vCenter was decommissioned or all its nodes were drained), so
+// dashboards don't keep showing a last-known-reachable value forever for a
+// vCenter the driver no longer tracks.
+func (m *defaultManager) pruneStaleHealthGauges(currentHosts map[string][]vcNode) {
+	m.healthCheckHosts.Range(func(hostInf, _ interface{}) bool {
+		host := hostInf.(string)
+		if _, stillPresent := currentHosts[host]; !stillPresent {
+			vcReachableGauge.DeleteLabelValues(host)
+			vcLatencyGauge.DeleteLabelValues(host)
+			nodesPerVCGauge.DeleteLabelValues(host)
+			m.healthCheckHosts.Delete(host)
+		}
+		return true
+	})
+	for vcHost := range currentHosts {
+		m.healthCheckHosts.Store(vcHost, struct{}{})
+	}
+}
+
+// maxHealthCheckSamples bounds how many of a vCenter's cached node VMs
+// checkVCHealth will try before giving up, so that one stale cache entry
+// (e.g. a VM deleted out-of-band) doesn't read as the whole vCenter being
+// unreachable.
+const maxHealthCheckSamples = 3
+
+// sampleNodes returns up to maxHealthCheckSamples of nodes for checkVCHealth
+// to probe.
+func sampleNodes(nodes []vcNode) []vcNode {
+	if len(nodes) > maxHealthCheckSamples {
+		return nodes[:maxHealthCheckSamples]
+	}
+	return nodes
+}
+
+// checkVCHealth probes a single vCenter by fetching the Name property of up
+// to maxHealthCheckSamples of its node VMs, stopping at the first success,
+// and records the outcome as Prometheus gauges.
+func (m *defaultManager) checkVCHealth(ctx context.Context, vcHost string, nodes []vcNode) VCHealth {
+	log := logger.GetLogger(ctx)
+	health := VCHealth{NodeCount: len(nodes)}
+
+	checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	samples := sampleNodes(nodes)
+
+	var (
+		name string
+		err  error
+	)
+	start := time.Now()
+	for _, n := range samples {
+		name, err = n.vm.VirtualMachine.ObjectName(checkCtx)
+		if err == nil {
+			break
+		}
+	}
+	health.LatencyMs = time.Since(start).Milliseconds()
+
+	if err != nil {
+		health.LastError = err
+		log.Warnf("vCenter %q appears unreachable during node manager health check (%d cached node(s), %d sampled): %v",
+			vcHost, health.NodeCount, len(samples), err)
+	} else {
+		health.Reachable = true
+		log.Debugf("vCenter %q is reachable (%dms, sampled via VM %q)", vcHost, health.LatencyMs, name)
+	}
+
+	vcReachableGauge.WithLabelValues(vcHost).Set(boolToFloat64(health.Reachable))
+	vcLatencyGauge.WithLabelValues(vcHost).Set(time.Duration(health.LatencyMs * int64(time.Millisecond)).Seconds())
+	nodesPerVCGauge.WithLabelValues(vcHost).Set(float64(health.NodeCount))
+
+	return health
+}
+
+func boolToFloat64(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
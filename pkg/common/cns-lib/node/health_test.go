@@ -0,0 +1,52 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import "testing"
+
+func TestSampleNodes(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int
+		want  int
+	}{
+		{"fewer than the cap", maxHealthCheckSamples - 1, maxHealthCheckSamples - 1},
+		{"exactly the cap", maxHealthCheckSamples, maxHealthCheckSamples},
+		{"more than the cap", maxHealthCheckSamples + 5, maxHealthCheckSamples},
+		{"empty", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes := make([]vcNode, tt.count)
+			for i := range nodes {
+				nodes[i] = vcNode{nodeUUID: string(rune('a' + i))}
+			}
+			if got := len(sampleNodes(nodes)); got != tt.want {
+				t.Errorf("len(sampleNodes(%d nodes)) = %d, want %d", tt.count, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBoolToFloat64(t *testing.T) {
+	if got := boolToFloat64(true); got != 1 {
+		t.Errorf("boolToFloat64(true) = %v, want 1", got)
+	}
+	if got := boolToFloat64(false); got != 0 {
+		t.Errorf("boolToFloat64(false) = %v, want 0", got)
+	}
+}
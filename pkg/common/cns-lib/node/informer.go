@@ -0,0 +1,252 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"context"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/vsphere-csi-driver/v2/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v2/pkg/kubernetes"
+)
+
+const (
+	// csiDriverName is the name CSINode objects use to key the vSphere CSI
+	// driver's node info within Spec.Drivers.
+	csiDriverName = "csi.vsphere.vmware.com"
+	// nodeInformerResyncPeriod is the full resync interval for the Node and
+	// CSINode informers backing the node manager cache.
+	nodeInformerResyncPeriod = 5 * time.Minute
+)
+
+// startInformers creates the Node and CSINode SharedInformers backing the
+// node manager cache and registers event handlers that keep nodeNameToUUID
+// and nodeVMs up to date as nodes come and go. It does not block; the
+// informers are started by Start once a stop channel is available.
+func (m *defaultManager) startInformers(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	m.informerFactory = informers.NewSharedInformerFactory(m.k8sClient, nodeInformerResyncPeriod)
+
+	m.nodeInformer = m.informerFactory.Core().V1().Nodes().Informer()
+	m.csiNodeInformer = m.informerFactory.Storage().V1().CSINodes().Informer()
+
+	if _, err := m.nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    m.handleNodeAdd,
+		UpdateFunc: m.handleNodeUpdate,
+		DeleteFunc: m.handleNodeDelete,
+	}); err != nil {
+		log.Errorf("failed to register Node informer event handler: %v", err)
+	}
+
+	if _, err := m.csiNodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    m.handleCSINodeAdd,
+		UpdateFunc: m.handleCSINodeUpdate,
+		DeleteFunc: m.handleCSINodeDelete,
+	}); err != nil {
+		log.Errorf("failed to register CSINode informer event handler: %v", err)
+	}
+
+	m.startSecretWatcher(ctx)
+}
+
+// Start starts the Node, CSINode and vSphere config Secret informers and
+// blocks until their caches have synced or stopCh is closed; ctx is used
+// for logging only and does not end the wait. It must be
+// called after SetKubernetesClient. Callers typically invoke it once during
+// driver startup, passing a stop channel tied to the process lifetime.
+func (m *defaultManager) Start(ctx context.Context, stopCh <-chan struct{}) error {
+	log := logger.GetLogger(ctx)
+	if m.informerFactory == nil {
+		return logger.LogNewErrorf(log, "node informers were not initialized, SetKubernetesClient must be called first")
+	}
+
+	log.Info("Starting node manager informers...")
+	m.informerFactory.Start(stopCh)
+	if m.secretInformerFactory != nil {
+		m.secretInformerFactory.Start(stopCh)
+	}
+
+	synced := m.informerFactory.WaitForCacheSync(stopCh)
+	for informerType, ok := range synced {
+		if !ok {
+			return logger.LogNewErrorf(log, "failed to sync informer cache for %v", informerType)
+		}
+	}
+	if m.secretInformerFactory != nil {
+		for informerType, ok := range m.secretInformerFactory.WaitForCacheSync(stopCh) {
+			if !ok {
+				return logger.LogNewErrorf(log, "failed to sync informer cache for %v", informerType)
+			}
+		}
+	}
+	log.Info("Node manager informers have synced")
+	return nil
+}
+
+// handleNodeAdd discovers the node's VM as soon as its UUID can be
+// determined from the Node object.
+func (m *defaultManager) handleNodeAdd(obj interface{}) {
+	node, ok := obj.(*v1.Node)
+	if !ok || m.useNodeUuid.Load() {
+		return
+	}
+	m.refreshNodeUUID(node.Name)
+}
+
+// handleNodeUpdate re-discovers the node whenever its reported SystemUUID
+// changes, e.g. after the VM backing the node is replaced. The raw
+// SystemUUID is only used to detect that change; refreshNodeUUID resolves
+// the UUID actually used through the same normalization RegisterNode uses.
+func (m *defaultManager) handleNodeUpdate(oldObj, newObj interface{}) {
+	if m.useNodeUuid.Load() {
+		return
+	}
+	oldNode, ok := oldObj.(*v1.Node)
+	if !ok {
+		return
+	}
+	newNode, ok := newObj.(*v1.Node)
+	if !ok {
+		return
+	}
+	if oldNode.Status.NodeInfo.SystemUUID == newNode.Status.NodeInfo.SystemUUID {
+		return
+	}
+	m.refreshNodeUUID(newNode.Name)
+}
+
+// handleNodeDelete removes the node from both caches.
+func (m *defaultManager) handleNodeDelete(obj interface{}) {
+	node, ok := m.asNode(obj)
+	if !ok || m.useNodeUuid.Load() {
+		return
+	}
+	m.forgetNode(node.Name)
+}
+
+// handleCSINodeAdd mirrors handleNodeAdd for clusters configured to read the
+// node UUID from the CSINode object instead of the Node object.
+func (m *defaultManager) handleCSINodeAdd(obj interface{}) {
+	csiNode, ok := obj.(*storagev1.CSINode)
+	if !ok || !m.useNodeUuid.Load() {
+		return
+	}
+	m.refreshNodeUUID(csiNode.Name)
+}
+
+// handleCSINodeUpdate mirrors handleNodeUpdate for CSINode-sourced UUIDs.
+func (m *defaultManager) handleCSINodeUpdate(oldObj, newObj interface{}) {
+	if !m.useNodeUuid.Load() {
+		return
+	}
+	oldCSINode, ok := oldObj.(*storagev1.CSINode)
+	if !ok {
+		return
+	}
+	newCSINode, ok := newObj.(*storagev1.CSINode)
+	if !ok {
+		return
+	}
+	if csiNodeDriverNodeID(oldCSINode) == csiNodeDriverNodeID(newCSINode) {
+		return
+	}
+	m.refreshNodeUUID(newCSINode.Name)
+}
+
+// handleCSINodeDelete removes the node from both caches.
+func (m *defaultManager) handleCSINodeDelete(obj interface{}) {
+	csiNode, ok := m.asCSINode(obj)
+	if !ok || !m.useNodeUuid.Load() {
+		return
+	}
+	m.forgetNode(csiNode.Name)
+}
+
+// refreshNodeUUID resolves nodeName's UUID via k8s.GetNodeUUID, the same
+// normalization RegisterNode uses, and triggers discovery of the backing
+// VM. A blank UUID is not cached, so the node stays unknown to the manager
+// until an update event reports a changed UUID and this runs again.
+func (m *defaultManager) refreshNodeUUID(nodeName string) {
+	ctx, log := logger.GetNewContextWithLogger()
+	nodeUUID, err := k8s.GetNodeUUID(ctx, m.k8sClient, nodeName, m.useNodeUuid.Load())
+	if err != nil {
+		log.Errorf("failed to get node UUID for node %q via informer: %v", nodeName, err)
+		return
+	}
+	if nodeUUID == "" {
+		return
+	}
+	m.nodeNameToUUID.Store(nodeName, nodeUUID)
+	if err := m.DiscoverNode(ctx, nodeUUID); err != nil {
+		log.Errorf("failed to discover VM with uuid: %q for node: %q added via informer: %v", nodeUUID, nodeName, err)
+	}
+}
+
+// forgetNode drops a node from both caches, used on Node/CSINode deletion.
+func (m *defaultManager) forgetNode(nodeName string) {
+	nodeUUID, found := m.nodeNameToUUID.Load(nodeName)
+	m.nodeNameToUUID.Delete(nodeName)
+	if found && nodeUUID != nil {
+		m.nodeVMs.Delete(nodeUUID)
+		m.nodeLocations.Delete(nodeUUID)
+		m.nodeMetadata.Delete(nodeUUID)
+	}
+}
+
+// asNode unwraps the object from a DeleteFunc callback, which may be a
+// cache.DeletedFinalStateUnknown when the delete event was missed.
+func (m *defaultManager) asNode(obj interface{}) (*v1.Node, bool) {
+	if node, ok := obj.(*v1.Node); ok {
+		return node, true
+	}
+	tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+	if !ok {
+		return nil, false
+	}
+	node, ok := tombstone.Obj.(*v1.Node)
+	return node, ok
+}
+
+// asCSINode unwraps the object from a DeleteFunc callback, which may be a
+// cache.DeletedFinalStateUnknown when the delete event was missed.
+func (m *defaultManager) asCSINode(obj interface{}) (*storagev1.CSINode, bool) {
+	if csiNode, ok := obj.(*storagev1.CSINode); ok {
+		return csiNode, true
+	}
+	tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+	if !ok {
+		return nil, false
+	}
+	csiNode, ok := tombstone.Obj.(*storagev1.CSINode)
+	return csiNode, ok
+}
+
+// csiNodeDriverNodeID returns the NodeID the vSphere CSI driver published on
+// the given CSINode, or the empty string if it hasn't registered yet.
+func csiNodeDriverNodeID(csiNode *storagev1.CSINode) string {
+	for _, driver := range csiNode.Spec.Drivers {
+		if driver.Name == csiDriverName {
+			return driver.NodeID
+		}
+	}
+	return ""
+}
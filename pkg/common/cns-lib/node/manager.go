@@ -20,11 +20,15 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
 
 	"sigs.k8s.io/vsphere-csi-driver/v2/pkg/csi/service/logger"
 
 	clientset "k8s.io/client-go/kubernetes"
 
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
 	"sigs.k8s.io/vsphere-csi-driver/v2/pkg/common/cns-lib/vsphere"
 	k8s "sigs.k8s.io/vsphere-csi-driver/v2/pkg/kubernetes"
 )
@@ -42,7 +46,15 @@ type Manager interface {
 	// K8s CSINode API object or the K8s Node API object to retrieve
 	// the node UUID.
 	SetUseNodeUuid(useNodeUuid bool)
-	// RegisterNode registers a node given its UUID, name.
+	// Start starts the Node and CSINode informers backing the node manager
+	// cache and blocks until their caches have synced or stopCh is closed.
+	// It must be called after SetKubernetesClient, once a stop channel is
+	// available, typically during driver startup.
+	Start(ctx context.Context, stopCh <-chan struct{}) error
+	// RegisterNode registers a node given its UUID, name. Nodes are
+	// auto-registered by the Node/CSINode informers started via Start;
+	// RegisterNode remains for callers (and tests) that want to seed or
+	// override the cache directly.
 	RegisterNode(ctx context.Context, nodeUUID string, nodeName string) error
 	// DiscoverNode discovers a registered node given its UUID. This method
 	// scans all virtual centers registered on the VirtualCenterManager for a
@@ -53,6 +65,39 @@ type Manager interface {
 	// datacenter given its UUID. If not, it will search in all registered
 	// datacenters.
 	GetNode(ctx context.Context, nodeUUID string, dc *vsphere.Datacenter) (*vsphere.VirtualMachine, error)
+	// GetNodeLocation returns the vCenter host and datacenter moRef that a
+	// node's VM was last discovered on, as cached from its most recent
+	// successful discovery. Returns ErrNodeNotFound if no location has been
+	// cached for nodeUUID yet.
+	GetNodeLocation(ctx context.Context, nodeUUID string) (vcHost string, datacenterMoRef string, err error)
+	// RefreshNodeLocation forces a full multi-VC rescan for a node and
+	// updates its cached location. Callers that detect the node's VM may
+	// have moved to a different vCenter or datacenter (e.g. a cross-DC
+	// vMotion) should call this instead of waiting for the next cache miss.
+	RefreshNodeLocation(ctx context.Context, nodeUUID string) error
+	// InvalidateVCache drops every cached node VM whose VirtualCenterHost
+	// matches vcHost, so the next GetNode/GetAllNodes call for that node
+	// re-authenticates and re-discovers it (a targeted lookup on its cached
+	// location, falling back to DiscoverNode) instead of reusing a
+	// VirtualMachine tied to a now-stale vCenter connection.
+	// Cached node locations are left intact since only the credentials
+	// changed, not the node's vCenter/datacenter. It is driven automatically
+	// by a Secret informer watching the vSphere config Secret, but can also
+	// be called directly.
+	InvalidateVCache(ctx context.Context, vcHost string)
+	// GetNodeMetadata returns the cached NodeMetadata for a node, as
+	// resolved during its most recent discovery. Returns ErrNodeNotFound if
+	// no metadata has been cached for nodeUUID yet.
+	GetNodeMetadata(ctx context.Context, nodeUUID string) (*NodeMetadata, error)
+	// HealthCheck groups cached nodes by vCenter and issues a lightweight
+	// property fetch per vCenter, against up to a few of its node VMs until
+	// one succeeds, to gauge reachability and latency, reporting the result
+	// per vCenter host. It also records
+	// the result as Prometheus gauges. Connectivity problems are logged at
+	// warn and reflected in the returned VCHealth, never as a returned
+	// error, so a struggling vCenter never causes the driver itself to
+	// degrade.
+	HealthCheck(ctx context.Context) (map[string]VCHealth, error)
 	// GetNodeByName refreshes and returns the VirtualMachine for a registered
 	// node given its name.
 	GetNodeByName(ctx context.Context, nodeName string) (*vsphere.VirtualMachine, error)
@@ -62,12 +107,41 @@ type Manager interface {
 	// nodes. If nodes are added or removed concurrently, they may or may not be
 	// reflected in the result of a call to this method.
 	GetAllNodes(ctx context.Context) ([]*vsphere.VirtualMachine, error)
-	// UnregisterNode unregisters a registered node given its name.
+	// GetAllNodesWithErrors is GetAllNodes, but also returns a PartialResult
+	// recording the per-node errors for any node dropped from the returned
+	// slice instead of aborting the whole call. Renewals are parallelized
+	// per vCenter so one slow or unreachable VC does not stall the others.
+	GetAllNodesWithErrors(ctx context.Context) ([]*vsphere.VirtualMachine, *PartialResult, error)
+	// UnregisterNode unregisters a registered node given its name. Nodes are
+	// auto-unregistered on deletion by the Node/CSINode informers started
+	// via Start; UnregisterNode remains for callers (and tests) that want
+	// to evict a node from the cache directly.
 	UnregisterNode(ctx context.Context, nodeName string) error
 }
 
-// Metadata represents node metadata.
-type Metadata interface{}
+// Metadata is kept as an alias for compatibility with callers written
+// against the original Metadata interface{} placeholder; use NodeMetadata
+// directly in new code.
+type Metadata = NodeMetadata
+
+// NodeMetadata represents topology and placement metadata for a node,
+// populated from vCenter and from the node's K8s topology labels whenever
+// the node is discovered.
+type NodeMetadata struct {
+	// Zone is the node's topology.csi.vmware.com/zone label value, if set.
+	Zone string
+	// Region is the node's topology.csi.vmware.com/region label value, if
+	// set.
+	Region string
+	// HostMoRef is the moRef of the ESXi host currently running the node's
+	// VM.
+	HostMoRef string
+	// ClusterMoRef is the moRef of the compute cluster the node's VM's host
+	// belongs to, empty if the host is standalone.
+	ClusterMoRef string
+	// DatastoreURLs lists the datastores accessible to the node's VM.
+	DatastoreURLs []string
+}
 
 var (
 	// managerInstance is a Manager singleton.
@@ -83,6 +157,7 @@ func GetManager(ctx context.Context) Manager {
 		log.Info("Initializing node.defaultManager...")
 		managerInstance = &defaultManager{
 			nodeVMs: sync.Map{},
+			stopCh:  make(chan struct{}),
 		}
 		log.Info("node.defaultManager initialized")
 	})
@@ -95,23 +170,65 @@ type defaultManager struct {
 	nodeVMs sync.Map
 	// node name to node UUI map.
 	nodeNameToUUID sync.Map
+	// nodeLocations maps node UUIDs to the vCenter/datacenter a node's VM
+	// was last discovered on. Unlike nodeVMs, an entry here survives cache
+	// invalidation of the VM itself, so a subsequent cache miss can retry a
+	// targeted lookup on the last-known location before falling back to a
+	// full multi-VC scan.
+	nodeLocations sync.Map
+	// nodeMetadata maps node UUIDs to their cached NodeMetadata, refreshed
+	// on every DiscoverNode.
+	nodeMetadata sync.Map
+	// healthCheckHosts is the set of vCenter hosts (as a map[string]struct{}
+	// via sync.Map) that were reported on the most recent HealthCheck, so a
+	// vCenter with no more cached nodes can have its gauges removed instead
+	// of left reporting stale values forever.
+	healthCheckHosts sync.Map
 	// k8s client.
 	k8sClient clientset.Interface
-	// useNodeUuid uses K8s CSINode API instead of
-	// K8s Node to retrieve the node UUID.
-	useNodeUuid bool
+	// useNodeUuid uses K8s CSINode API instead of K8s Node to retrieve the
+	// node UUID. Set via SetUseNodeUuid and read from informer goroutines,
+	// so it's accessed through atomic.Bool rather than as a plain bool.
+	useNodeUuid atomic.Bool
+	// informerFactory backs the Node and CSINode informers used to
+	// auto-register and auto-unregister nodes as they are added, updated
+	// and deleted.
+	informerFactory informers.SharedInformerFactory
+	// nodeInformer watches v1.Node objects.
+	nodeInformer cache.SharedIndexInformer
+	// csiNodeInformer watches storagev1.CSINode objects.
+	csiNodeInformer cache.SharedIndexInformer
+	// secretInformerFactory backs secretInformer. It is namespace-scoped
+	// separately from informerFactory, which watches cluster-wide objects.
+	secretInformerFactory informers.SharedInformerFactory
+	// secretInformer watches the vSphere config Secret for rotations.
+	secretInformer cache.SharedIndexInformer
+	// stopCh is closed to tear down the informers started from
+	// SetKubernetesClient. It's separate from the stopCh a caller may pass
+	// to Start directly.
+	stopCh chan struct{}
 }
 
-// SetKubernetesClient sets specified kubernetes client to defaultManager.k8sClient
+// SetKubernetesClient sets specified kubernetes client to
+// defaultManager.k8sClient, initializes the Node, CSINode and Secret
+// informers that keep the node cache up to date, and starts them in the
+// background.
 func (m *defaultManager) SetKubernetesClient(client clientset.Interface) {
 	m.k8sClient = client
+	ctx, log := logger.GetNewContextWithLogger()
+	m.startInformers(ctx)
+	go func() {
+		if err := m.Start(ctx, m.stopCh); err != nil {
+			log.Errorf("failed to start node manager informers: %v", err)
+		}
+	}()
 }
 
 // SetUseNodeUuid sets whether the node manager should use
 // K8s CSINode API object or the K8s Node API object to retrieve
 // node UUID.
 func (m *defaultManager) SetUseNodeUuid(useNodeUuid bool) {
-	m.useNodeUuid = useNodeUuid
+	m.useNodeUuid.Store(useNodeUuid)
 }
 
 // RegisterNode registers a node with node manager using its UUID, name.
@@ -139,10 +256,115 @@ func (m *defaultManager) DiscoverNode(ctx context.Context, nodeUUID string) erro
 		return err
 	}
 	m.nodeVMs.Store(nodeUUID, vm)
+	m.storeNodeLocation(nodeUUID, vm)
+	m.populateNodeMetadata(ctx, nodeUUID, vm)
 	log.Infof("Successfully discovered node with nodeUUID %s in vm %v", nodeUUID, vm)
 	return nil
 }
 
+// storeNodeLocation caches the vCenter host and datacenter moRef that vm was
+// resolved on, so a future cache miss for nodeUUID can attempt a targeted
+// lookup instead of a full multi-VC scan.
+func (m *defaultManager) storeNodeLocation(nodeUUID string, vm *vsphere.VirtualMachine) {
+	if vm.Datacenter == nil {
+		return
+	}
+	m.nodeLocations.Store(nodeUUID, nodeLocation{
+		vcHost:          vm.VirtualCenterHost,
+		datacenterMoRef: vm.Datacenter.Reference().Value,
+	})
+}
+
+// loadNodeLocation returns the cached location for nodeUUID, if any.
+func (m *defaultManager) loadNodeLocation(nodeUUID string) (nodeLocation, bool) {
+	locInf, found := m.nodeLocations.Load(nodeUUID)
+	if !found {
+		return nodeLocation{}, false
+	}
+	return locInf.(nodeLocation), true
+}
+
+// nodeLocation records the vCenter/datacenter a node's VM was last
+// discovered on.
+type nodeLocation struct {
+	// vcHost is the vCenter host the VM was found on.
+	vcHost string
+	// datacenterMoRef is the moRef of the datacenter the VM was found in.
+	datacenterMoRef string
+}
+
+// InvalidateVCache drops every cached node VM whose VirtualCenterHost
+// matches vcHost. Cached node locations are left untouched since they
+// remain valid; only the VirtualMachine's connection needs to be
+// re-established, which the next GetNode or GetAllNodes call for the node
+// does on its cache miss, with freshly loaded credentials.
+func (m *defaultManager) InvalidateVCache(ctx context.Context, vcHost string) {
+	log := logger.GetLogger(ctx)
+	var invalidated int
+	m.nodeVMs.Range(func(nodeUUIDInf, vmInf interface{}) bool {
+		vm, ok := vmInf.(*vsphere.VirtualMachine)
+		if !ok || vm == nil || vm.VirtualCenterHost != vcHost {
+			return true
+		}
+		m.nodeVMs.Delete(nodeUUIDInf)
+		invalidated++
+		return true
+	})
+	log.Infof("Invalidated %d cached node VM(s) for vCenter %q following a credential/config change", invalidated, vcHost)
+}
+
+// GetNodeLocation returns the cached vCenter host and datacenter moRef for a
+// node, as recorded on its most recent successful discovery.
+func (m *defaultManager) GetNodeLocation(ctx context.Context, nodeUUID string) (string, string, error) {
+	log := logger.GetLogger(ctx)
+	loc, found := m.loadNodeLocation(nodeUUID)
+	if !found {
+		log.Errorf("no cached location found for node with nodeUUID %s", nodeUUID)
+		return "", "", ErrNodeNotFound
+	}
+	return loc.vcHost, loc.datacenterMoRef, nil
+}
+
+// RefreshNodeLocation forces a full multi-VC rescan for a node, evicting its
+// cached VM and location first so the rescan can't short-circuit back
+// through the now-stale targeted lookup in GetNode.
+func (m *defaultManager) RefreshNodeLocation(ctx context.Context, nodeUUID string) error {
+	log := logger.GetLogger(ctx)
+	log.Infof("Refreshing location for node with nodeUUID %s via a full vCenter scan", nodeUUID)
+	m.nodeVMs.Delete(nodeUUID)
+	m.nodeLocations.Delete(nodeUUID)
+	if err := m.DiscoverNode(ctx, nodeUUID); err != nil {
+		log.Errorf("failed to refresh location for node with nodeUUID %s: %v", nodeUUID, err)
+		return err
+	}
+	return nil
+}
+
+// resolveDatacenter looks up the *vsphere.Datacenter for a cached
+// (vcHost, datacenterMoRef) location. vsphere has no datacenter-by-moref
+// constructor, so this goes through the VirtualCenterManager the same way
+// DiscoverNode's full scan does.
+func resolveDatacenter(ctx context.Context, vcHost, datacenterMoRef string) (*vsphere.Datacenter, error) {
+	log := logger.GetLogger(ctx)
+	vc, err := vsphere.GetVirtualCenterManager(ctx).GetVirtualCenter(ctx, vcHost)
+	if err != nil {
+		return nil, err
+	}
+	if err := vc.Connect(ctx); err != nil {
+		return nil, err
+	}
+	datacenters, err := vc.GetDatacenters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, dc := range datacenters {
+		if dc.Reference().Value == datacenterMoRef {
+			return dc, nil
+		}
+	}
+	return nil, logger.LogNewErrorf(log, "datacenter with moRef %q not found on vCenter %q", datacenterMoRef, vcHost)
+}
+
 // GetNodeByName refreshes and returns the VirtualMachine for a registered node
 // given its name.
 func (m *defaultManager) GetNodeByName(ctx context.Context, nodeName string) (*vsphere.VirtualMachine, error) {
@@ -157,7 +379,7 @@ func (m *defaultManager) GetNodeByName(ctx context.Context, nodeName string) (*v
 	}
 	log.Infof("Empty nodeUUID observed in cache for the node: %q", nodeName)
 	k8snodeUUID, err := k8s.GetNodeUUID(ctx, m.k8sClient, nodeName,
-		m.useNodeUuid)
+		m.useNodeUuid.Load())
 	if err != nil {
 		log.Errorf("failed to get node UUID from node: %q. Err: %v", nodeName, err)
 		return nil, err
@@ -202,6 +424,29 @@ func (m *defaultManager) GetNode(ctx context.Context,
 				return nil, err
 			}
 			m.nodeVMs.Store(nodeUUID, vm)
+			m.storeNodeLocation(nodeUUID, vm)
+			m.populateNodeMetadata(ctx, nodeUUID, vm)
+		} else if loc, found := m.loadNodeLocation(nodeUUID); found {
+			log.Infof("Attempting targeted lookup for node with nodeUUID %s on cached location vc %q, datacenter %q",
+				nodeUUID, loc.vcHost, loc.datacenterMoRef)
+			cachedDC, dcErr := resolveDatacenter(ctx, loc.vcHost, loc.datacenterMoRef)
+			if dcErr == nil {
+				vm, err = cachedDC.GetVirtualMachineByUUID(ctx, nodeUUID, false)
+			}
+			if dcErr != nil || err != nil {
+				log.Warnf("cached location for node with nodeUUID %s is stale (vc %q, datacenter %q), "+
+					"falling back to full scan: %v", nodeUUID, loc.vcHost, loc.datacenterMoRef, firstNonNil(dcErr, err))
+				if err = m.DiscoverNode(ctx, nodeUUID); err != nil {
+					log.Errorf("failed to discover node with nodeUUID %s with err: %v", nodeUUID, err)
+					return nil, err
+				}
+				vmInf, _ = m.nodeVMs.Load(nodeUUID)
+				vm = vmInf.(*vsphere.VirtualMachine)
+			} else {
+				m.nodeVMs.Store(nodeUUID, vm)
+				m.storeNodeLocation(nodeUUID, vm)
+				m.populateNodeMetadata(ctx, nodeUUID, vm)
+			}
 		} else {
 			if err = m.DiscoverNode(ctx, nodeUUID); err != nil {
 				log.Errorf("failed to discover node with nodeUUID %s with err: %v", nodeUUID, err)
@@ -229,69 +474,84 @@ func (m *defaultManager) GetNode(ctx context.Context,
 
 // GetAllNodes refreshes and returns VirtualMachine for all registered nodes.
 func (m *defaultManager) GetAllNodes(ctx context.Context) ([]*vsphere.VirtualMachine, error) {
+	vms, _, err := m.GetAllNodesWithErrors(ctx)
+	return vms, err
+}
+
+// GetAllNodesWithErrors is GetAllNodes, but also returns a PartialResult
+// recording the per-node errors for any node dropped from the returned
+// slice. Renewals are grouped by VirtualCenterHost and run one goroutine
+// per VC, bounded by maxConcurrentVCRenewals.
+func (m *defaultManager) GetAllNodesWithErrors(ctx context.Context) ([]*vsphere.VirtualMachine, *PartialResult, error) {
 	log := logger.GetLogger(ctx)
-	var vms []*vsphere.VirtualMachine
-	var err error
-	reconnectedHosts := make(map[string]bool)
+	result := &PartialResult{Errors: make(map[string]error)}
 
 	m.nodeNameToUUID.Range(func(nodeName, nodeUUID interface{}) bool {
 		if nodeName != nil && nodeUUID != nil && nodeUUID.(string) == "" {
 			log.Infof("Empty node UUID observed for the node: %q", nodeName)
 			k8snodeUUID, err := k8s.GetNodeUUID(ctx, m.k8sClient,
-				nodeName.(string), m.useNodeUuid)
+				nodeName.(string), m.useNodeUuid.Load())
 			if err != nil {
 				log.Errorf("failed to get node UUID from node: %q. Err: %v", nodeName, err)
+				result.Errors[nodeName.(string)] = err
 				return true
 			}
 			if k8snodeUUID == "" {
-				log.Errorf("Node: %q with empty node UUID found in the cluster. "+
-					"aborting get all nodes", nodeName)
+				log.Errorf("Node: %q with empty node UUID found in the cluster, skipping", nodeName)
+				result.Errors[nodeName.(string)] = logger.LogNewErrorf(log,
+					"node %q has no discoverable UUID", nodeName)
 				return true
 			}
 			m.nodeNameToUUID.Store(nodeName, k8snodeUUID)
-			return false
-		}
-		return true
-	})
-
-	if err != nil {
-		return nil, err
-	}
-	m.nodeVMs.Range(func(nodeUUIDInf, vmInf interface{}) bool {
-		// If an entry was concurrently deleted from vm, Range could
-		// possibly return a nil value for that key.
-		// See https://golang.org/pkg/sync/#Map.Range for more info.
-		if vmInf == nil {
-			log.Warnf("VM instance was nil, ignoring with nodeUUID %v", nodeUUIDInf)
-			return true
-		}
-
-		nodeUUID := nodeUUIDInf.(string)
-		vm := vmInf.(*vsphere.VirtualMachine)
-
-		if reconnectedHosts[vm.VirtualCenterHost] {
-			log.Debugf("Renewing VM %v, no new connection needed: nodeUUID %s", vm, nodeUUID)
-			err = vm.Renew(ctx, false)
-		} else {
-			log.Debugf("Renewing VM %v with new connection: nodeUUID %s", vm, nodeUUID)
-			err = vm.Renew(ctx, true)
-			reconnectedHosts[vm.VirtualCenterHost] = true
+			nodeUUID = k8snodeUUID
 		}
-
-		if err != nil {
-			log.Errorf("failed to renew VM %v with nodeUUID %s, aborting get all nodes", vm, nodeUUID)
-			return false
+		// Re-discover nodes missing from nodeVMs, e.g. after InvalidateVCache
+		// dropped them, so they are renewed below with the others.
+		if uuid, ok := nodeUUID.(string); ok && uuid != "" {
+			if _, cached := m.nodeVMs.Load(uuid); !cached {
+				if _, err := m.GetNode(ctx, uuid, nil); err != nil {
+					result.Errors[uuid] = err
+				}
+			}
 		}
-
-		log.Debugf("Updated VM %v for node with nodeUUID %s", vm, nodeUUID)
-		vms = append(vms, vm)
 		return true
 	})
 
-	if err != nil {
-		return nil, err
+	nodesByHost := m.groupNodeVMsByHost(ctx)
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		vms []*vsphere.VirtualMachine
+		sem = make(chan struct{}, maxConcurrentVCRenewals)
+	)
+	for vcHost, nodes := range nodesByHost {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(vcHost string, nodes []vcNode) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			for i, n := range nodes {
+				// Only the first renewal per VC needs to re-establish the
+				// connection; the rest reuse it.
+				if err := renewWithBackoff(ctx, n.vm, i == 0); err != nil {
+					log.Errorf("failed to renew VM %v with nodeUUID %s on vCenter %q, dropping from result: %v",
+						n.vm, n.nodeUUID, vcHost, err)
+					mu.Lock()
+					result.Errors[n.nodeUUID] = err
+					mu.Unlock()
+					continue
+				}
+				log.Debugf("Updated VM %v for node with nodeUUID %s", n.vm, n.nodeUUID)
+				mu.Lock()
+				vms = append(vms, n.vm)
+				mu.Unlock()
+			}
+		}(vcHost, nodes)
 	}
-	return vms, nil
+	wg.Wait()
+
+	return vms, result, nil
 }
 
 // UnregisterNode unregisters a registered node given its name.
@@ -304,6 +564,31 @@ func (m *defaultManager) UnregisterNode(ctx context.Context, nodeName string) er
 	}
 	m.nodeNameToUUID.Delete(nodeName)
 	m.nodeVMs.Delete(nodeUUID)
+	m.nodeLocations.Delete(nodeUUID)
+	m.nodeMetadata.Delete(nodeUUID)
 	log.Infof("Successfully unregistered node with nodeName %s", nodeName)
 	return nil
 }
+
+// GetNodeMetadata returns the cached NodeMetadata for a node, as resolved
+// during its most recent discovery.
+func (m *defaultManager) GetNodeMetadata(ctx context.Context, nodeUUID string) (*NodeMetadata, error) {
+	log := logger.GetLogger(ctx)
+	metaInf, found := m.nodeMetadata.Load(nodeUUID)
+	if !found {
+		log.Errorf("no cached metadata found for node with nodeUUID %s", nodeUUID)
+		return nil, ErrNodeNotFound
+	}
+	return metaInf.(*NodeMetadata), nil
+}
+
+// firstNonNil returns the first non-nil error among errs, or nil if all are
+// nil.
+func firstNonNil(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
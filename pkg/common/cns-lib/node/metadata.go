@@ -0,0 +1,116 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"context"
+
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/vsphere-csi-driver/v2/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v2/pkg/csi/service/logger"
+)
+
+const (
+	// zoneLabel is the well-known K8s Node label the driver's topology
+	// reconciliation flows stamp with the node's vSphere CSI topology zone.
+	zoneLabel = "topology.csi.vmware.com/zone"
+	// regionLabel is the well-known K8s Node label the driver's topology
+	// reconciliation flows stamp with the node's vSphere CSI topology
+	// region.
+	regionLabel = "topology.csi.vmware.com/region"
+)
+
+// populateNodeMetadata resolves and caches NodeMetadata for a node whose VM
+// was just discovered. Failures resolving vCenter-side topology are logged
+// but don't fail discovery itself, since the VM is already usable without
+// it; the Node's topology labels are best-effort in the same way.
+func (m *defaultManager) populateNodeMetadata(ctx context.Context, nodeUUID string, vm *vsphere.VirtualMachine) {
+	log := logger.GetLogger(ctx)
+	meta := &NodeMetadata{}
+
+	hostMoRef, clusterMoRef, datastoreURLs, err := hostClusterAndDatastores(ctx, vm)
+	if err != nil {
+		log.Warnf("failed to resolve host/cluster/datastore topology for node with nodeUUID %s: %v", nodeUUID, err)
+	} else {
+		meta.HostMoRef = hostMoRef
+		meta.ClusterMoRef = clusterMoRef
+		meta.DatastoreURLs = datastoreURLs
+	}
+
+	if nodeName, err := m.GetNodeNameByUUID(ctx, nodeUUID); err == nil {
+		meta.Zone, meta.Region = m.topologyLabelsForNode(nodeName)
+	}
+
+	m.nodeMetadata.Store(nodeUUID, meta)
+}
+
+// hostClusterAndDatastores resolves vm's current ESXi host, the moRef of the
+// host's compute cluster (empty if standalone), and the URLs of the
+// datastores accessible to that host, via the govmomi HostSystem object and
+// a PropertyCollector fetch.
+func hostClusterAndDatastores(ctx context.Context, vm *vsphere.VirtualMachine) (hostMoRef, clusterMoRef string, datastoreURLs []string, err error) {
+	log := logger.GetLogger(ctx)
+
+	host, err := vm.VirtualMachine.HostSystem(ctx)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var hostProps mo.HostSystem
+	pc := property.DefaultCollector(vm.VirtualMachine.Client())
+	if err := pc.RetrieveOne(ctx, host.Reference(), []string{"parent", "datastore"}, &hostProps); err != nil {
+		return "", "", nil, err
+	}
+
+	hostMoRef = host.Reference().Value
+	if hostProps.Parent != nil && hostProps.Parent.Type == "ClusterComputeResource" {
+		clusterMoRef = hostProps.Parent.Value
+	}
+
+	for _, dsRef := range hostProps.Datastore {
+		var ds mo.Datastore
+		if err := pc.RetrieveOne(ctx, dsRef, []string{"summary"}, &ds); err != nil {
+			log.Warnf("failed to resolve datastore %v accessible to host %q: %v", dsRef, hostMoRef, err)
+			continue
+		}
+		datastoreURLs = append(datastoreURLs, ds.Summary.Url)
+	}
+
+	return hostMoRef, clusterMoRef, datastoreURLs, nil
+}
+
+// topologyLabelsForNode returns the zone and region labels cached on the
+// K8s Node object for nodeName, from the same informer cache backing
+// auto-registration. Returns empty strings if the node informer hasn't
+// synced yet or the node has no such labels.
+func (m *defaultManager) topologyLabelsForNode(nodeName string) (zone string, region string) {
+	if m.nodeInformer == nil {
+		return "", ""
+	}
+	obj, exists, err := m.nodeInformer.GetIndexer().GetByKey(nodeName)
+	if err != nil || !exists {
+		return "", ""
+	}
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return "", ""
+	}
+	return node.Labels[zoneLabel], node.Labels[regionLabel]
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/vsphere-csi-driver/v2/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v2/pkg/csi/service/logger"
+)
+
+const (
+	// maxConcurrentVCRenewals bounds how many vCenters GetAllNodes renews
+	// concurrently, so a cluster with many VCs doesn't open an unbounded
+	// number of simultaneous connections.
+	maxConcurrentVCRenewals = 8
+	// maxRenewAttempts is the total number of attempts made at a single VM
+	// renewal (the first try plus retries after transient errors) before
+	// it's given up on.
+	maxRenewAttempts = 3
+	// renewBackoffBase is the base delay for the exponential backoff
+	// between renewal retries.
+	renewBackoffBase = 200 * time.Millisecond
+)
+
+// PartialResult carries per-node errors for a GetAllNodes call that
+// completed with some, but not all, nodes renewed successfully.
+type PartialResult struct {
+	// Errors maps a node's UUID (or name, for nodes that couldn't be
+	// resolved to a UUID at all) to the error encountered renewing it.
+	Errors map[string]error
+}
+
+// vcNode pairs a cached VirtualMachine with its node UUID for the purposes
+// of grouping cached nodes by vCenter, e.g. in GetAllNodes and HealthCheck.
+type vcNode struct {
+	nodeUUID string
+	vm       *vsphere.VirtualMachine
+}
+
+// groupNodeVMsByHost groups every currently cached node VM by its
+// VirtualCenterHost, so callers can fan work for a VC's nodes out to its own
+// goroutine without one slow/unreachable VC blocking the others.
+func (m *defaultManager) groupNodeVMsByHost(ctx context.Context) map[string][]vcNode {
+	log := logger.GetLogger(ctx)
+	nodesByHost := make(map[string][]vcNode)
+	m.nodeVMs.Range(func(nodeUUIDInf, vmInf interface{}) bool {
+		// If an entry was concurrently deleted from vm, Range could
+		// possibly return a nil value for that key.
+		// See https://golang.org/pkg/sync/#Map.Range for more info.
+		if vmInf == nil {
+			log.Warnf("VM instance was nil, ignoring with nodeUUID %v", nodeUUIDInf)
+			return true
+		}
+		vm := vmInf.(*vsphere.VirtualMachine)
+		nodesByHost[vm.VirtualCenterHost] = append(nodesByHost[vm.VirtualCenterHost], vcNode{
+			nodeUUID: nodeUUIDInf.(string),
+			vm:       vm,
+		})
+		return true
+	})
+	return nodesByHost
+}
+
+// renewWithBackoff renews vm, retrying transient errors with exponential
+// backoff and jitter before giving up. Non-transient errors are returned
+// immediately.
+func renewWithBackoff(ctx context.Context, vm *vsphere.VirtualMachine, forceRefresh bool) error {
+	log := logger.GetLogger(ctx)
+	var err error
+	for attempt := 0; attempt < maxRenewAttempts; attempt++ {
+		if err = vm.Renew(ctx, forceRefresh); err == nil {
+			return nil
+		}
+		if !isTransientRenewError(err) {
+			return err
+		}
+		if attempt == maxRenewAttempts-1 {
+			break
+		}
+		backoff := renewBackoffBase << attempt
+		sleep := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2+1)))
+		log.Warnf("transient error renewing VM %v (attempt %d/%d), retrying in %v: %v",
+			vm, attempt+1, maxRenewAttempts, sleep, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(sleep):
+		}
+	}
+	return err
+}
+
+// isTransientRenewError reports whether err looks like a transient
+// connectivity problem (timeout, reset connection, SOAP fault) worth
+// retrying, as opposed to a permanent failure like the VM no longer
+// existing.
+func isTransientRenewError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	msg := err.Error()
+	for _, substr := range []string{
+		"EOF",
+		"connection reset",
+		"i/o timeout",
+		"broken pipe",
+		"ServerFaultCode",
+		"TCP",
+		"context deadline exceeded",
+	} {
+		if strings.Contains(msg, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"sigs.k8s.io/vsphere-csi-driver/v2/pkg/common/cns-lib/vsphere"
+)
+
+func TestIsTransientRenewError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil error", nil, false},
+		{"deadline exceeded", context.DeadlineExceeded, true},
+		{"wrapped deadline exceeded", fmt.Errorf("renewing VM: %w", context.DeadlineExceeded), true},
+		{"EOF substring", errors.New("unexpected EOF"), true},
+		{"connection reset substring", errors.New("read: connection reset by peer"), true},
+		{"i/o timeout substring", errors.New("dial tcp: i/o timeout"), true},
+		{"broken pipe substring", errors.New("write: broken pipe"), true},
+		{"ServerFaultCode substring", errors.New("ServerFaultCode: NotAuthenticated"), true},
+		{"permanent not found error", errors.New("virtual machine not found"), false},
+		{"permission denied error", errors.New("permission denied"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientRenewError(tt.err); got != tt.want {
+				t.Errorf("isTransientRenewError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupNodeVMsByHost(t *testing.T) {
+	m := &defaultManager{}
+	m.nodeVMs.Store("uuid-1", &vsphere.VirtualMachine{VirtualCenterHost: "vc-a"})
+	m.nodeVMs.Store("uuid-2", &vsphere.VirtualMachine{VirtualCenterHost: "vc-a"})
+	m.nodeVMs.Store("uuid-3", &vsphere.VirtualMachine{VirtualCenterHost: "vc-b"})
+	m.nodeVMs.Store("uuid-4", nil)
+
+	grouped := m.groupNodeVMsByHost(context.Background())
+
+	if got := len(grouped["vc-a"]); got != 2 {
+		t.Errorf("len(grouped[%q]) = %d, want 2", "vc-a", got)
+	}
+	if got := len(grouped["vc-b"]); got != 1 {
+		t.Errorf("len(grouped[%q]) = %d, want 1", "vc-b", got)
+	}
+	if _, ok := grouped[""]; ok {
+		t.Errorf("grouped contains an entry for a nil VM, should have been skipped")
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"context"
+	"os"
+	"regexp"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/vsphere-csi-driver/v2/pkg/csi/service/logger"
+)
+
+const (
+	// vSphereConfigSecretName is the default name of the Secret holding the
+	// vSphere CSI driver's vCenter config, as created by the CSI driver
+	// install manifests.
+	vSphereConfigSecretName = "vsphere-config-secret"
+	// vSphereConfigSecretNamespaceEnvVar names the environment variable the
+	// driver pod uses (via the downward API) to learn its own namespace,
+	// which is also where the config Secret lives.
+	vSphereConfigSecretNamespaceEnvVar = "POD_NAMESPACE"
+	// defaultVSphereConfigSecretNamespace is used if
+	// vSphereConfigSecretNamespaceEnvVar isn't set.
+	defaultVSphereConfigSecretNamespace = "kube-system"
+)
+
+// virtualCenterSectionRe matches "[VirtualCenter "<host>"]" section headers
+// in the driver's INI-style vSphere config, the same format csi-vsphere.conf
+// is parsed with elsewhere in the driver.
+var virtualCenterSectionRe = regexp.MustCompile(`(?i)\[\s*VirtualCenter\s+"([^"]+)"\s*\]`)
+
+// startSecretWatcher creates (but does not start) an informer on the
+// vSphere config Secret so that InvalidateVCache is called automatically
+// whenever the Secret's ResourceVersion changes for a given vCenter, e.g.
+// after an operator rotates SSO credentials. The informer is started
+// alongside the node/CSINode informers by Start.
+func (m *defaultManager) startSecretWatcher(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	namespace := os.Getenv(vSphereConfigSecretNamespaceEnvVar)
+	if namespace == "" {
+		namespace = defaultVSphereConfigSecretNamespace
+	}
+
+	m.secretInformerFactory = informers.NewSharedInformerFactoryWithOptions(m.k8sClient, nodeInformerResyncPeriod,
+		informers.WithNamespace(namespace))
+	m.secretInformer = m.secretInformerFactory.Core().V1().Secrets().Informer()
+
+	if _, err := m.secretInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: m.handleConfigSecretUpdate,
+	}); err != nil {
+		log.Errorf("failed to register Secret informer event handler: %v", err)
+	}
+}
+
+// handleConfigSecretUpdate invalidates the cached node VMs for every vCenter
+// named in the updated Secret, but only when its ResourceVersion actually
+// changed (periodic resyncs re-deliver Update events with identical
+// content).
+func (m *defaultManager) handleConfigSecretUpdate(oldObj, newObj interface{}) {
+	oldSecret, ok := oldObj.(*v1.Secret)
+	if !ok {
+		return
+	}
+	newSecret, ok := newObj.(*v1.Secret)
+	if !ok || newSecret.Name != vSphereConfigSecretName {
+		return
+	}
+	if oldSecret.ResourceVersion == newSecret.ResourceVersion {
+		return
+	}
+
+	ctx, log := logger.GetNewContextWithLogger()
+	vcHosts := vCenterHostsFromConfigSecret(newSecret)
+	if len(vcHosts) == 0 {
+		log.Warnf("vSphere config secret %q was updated but no VirtualCenter sections could be parsed from it",
+			newSecret.Name)
+		return
+	}
+	for _, vcHost := range vcHosts {
+		log.Infof("vSphere config secret %q changed, invalidating cached node VMs for vCenter %q",
+			newSecret.Name, vcHost)
+		m.InvalidateVCache(ctx, vcHost)
+	}
+}
+
+// vCenterHostsFromConfigSecret extracts every vCenter host named by a
+// "[VirtualCenter "<host>"]" section across all keys of the Secret's data.
+func vCenterHostsFromConfigSecret(secret *v1.Secret) []string {
+	var hosts []string
+	for _, data := range secret.Data {
+		for _, match := range virtualCenterSectionRe.FindAllStringSubmatch(string(data), -1) {
+			hosts = append(hosts, match[1])
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVCenterHostsFromConfigSecret(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string][]byte
+		want []string
+	}{
+		{
+			name: "single section",
+			data: map[string][]byte{
+				"csi-vsphere.conf": []byte(`[VirtualCenter "vc1.example.com"]
+user = "administrator@vsphere.local"
+`),
+			},
+			want: []string{"vc1.example.com"},
+		},
+		{
+			name: "multiple sections across keys",
+			data: map[string][]byte{
+				"csi-vsphere.conf": []byte(`[VirtualCenter "vc1.example.com"]
+[VirtualCenter "vc2.example.com"]
+`),
+				"extra.conf": []byte(`[virtualcenter "vc3.example.com"]`),
+			},
+			want: []string{"vc1.example.com", "vc2.example.com", "vc3.example.com"},
+		},
+		{
+			name: "no sections",
+			data: map[string][]byte{
+				"csi-vsphere.conf": []byte(`[Global]
+cluster-id = "test"
+`),
+			},
+			want: nil,
+		},
+		{
+			name: "extra whitespace in section header",
+			data: map[string][]byte{
+				"csi-vsphere.conf": []byte(`[ VirtualCenter   "vc1.example.com" ]`),
+			},
+			want: []string{"vc1.example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: vSphereConfigSecretName},
+				Data:       tt.data,
+			}
+			got := vCenterHostsFromConfigSecret(secret)
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("vCenterHostsFromConfigSecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}